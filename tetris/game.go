@@ -5,7 +5,6 @@ package tetris
 import (
 	"github.com/nsf/termbox-go"
 	"math"
-	"math/rand"
 	"time"
 )
 
@@ -19,46 +18,81 @@ const (
 )
 
 // A Game tracks the entire game state of tetris, including the Board, the upcoming piece, the game speed
-// (dropDelayMillis), the score, and various other internal data.
+// (dropDelayMillis), the score, level, lines cleared, and various other internal data.
 type Game struct {
 	board           *Board
 	nextPiece       *Piece
+	heldPiece       *Piece
+	holdUsed        bool
 	pieces          []Piece
+	bag             *Bag
 	paused          bool
 	over            bool
+	ghostEnabled    bool
 	dropDelayMillis int
 	ticker          *time.Ticker
 	score           int
+	level           int
+	linesCleared    int
+	// onLinesCleared, if set, is called with the number of rows cleared whenever a piece anchors and clears
+	// at least one row. NetGame uses this to send GARBAGE to the opponent.
+	onLinesCleared func(rowsCleared int)
+	// silent marks a mirror game (NetGame's remote) that must never draw, block, or tick on its own.
+	silent bool
 }
 
 // Initialize a new game, ready to be started with Start().
 func NewGame() *Game {
+	return newGame(tetrisPieces(), time.Now().UnixNano(), false)
+}
+
+// Initialize a new game using a custom set of pieces instead of the built-in tetrominoes (see LoadPieces),
+// ready to be started with Start().
+func NewGameWithPieces(pieces []Piece) *Game {
+	return newGame(pieces, time.Now().UnixNano(), false)
+}
+
+// newGame does the real work of initializing a Game, drawing pieces from a bag seeded with seed. A shared
+// seed is what lets a NetGame keep both sides' 7-bags in lockstep.
+func newGame(pieces []Piece, seed int64, silent bool) *Game {
 	game := new(Game)
-	game.pieces = tetrisPieces()
+	game.pieces = pieces
+	game.bag = newBag(len(pieces), seed)
 	game.board = newBoard()
+	game.silent = silent
 	game.board.currentPiece = game.GeneratePiece()
-	game.board.currentPosition = Vector{initialX, 0}
+	game.board.currentPosition = game.board.currentPiece.initialLocation
 	game.nextPiece = game.GeneratePiece()
 	game.paused = false
 	game.over = false
+	game.ghostEnabled = true
 	game.score = 0
+	game.level = 1
+	game.linesCleared = 0
 	game.startTicker()
 	return game
 }
 
-// Start up the ticker with the appropriate interval based on the current score.
+// Start up the ticker with the appropriate interval for the current level. A silent game never ticks.
 func (game *Game) startTicker() {
-	// Set the speed as a function of score. Starts at 800ms, decreases to 200ms by 100ms each 500 points.
-	game.dropDelayMillis = 800 - game.score/5
-	if game.dropDelayMillis < 200 {
-		game.dropDelayMillis = 200
+	if game.silent {
+		return
+	}
+	// Drop delay follows the classic guideline formula: seconds = (0.8-(level-1)*0.007)^(level-1).
+	seconds := math.Pow(0.8-float64(game.level-1)*0.007, float64(game.level-1))
+	game.dropDelayMillis = int(seconds * 1000)
+	// The formula tends to 0 at high levels; floor it so NewTicker never sees a non-positive interval.
+	if game.dropDelayMillis < 16 {
+		game.dropDelayMillis = 16
 	}
 	game.ticker = time.NewTicker(time.Duration(game.dropDelayMillis) * time.Millisecond)
 }
 
 // Stop the game ticker. This stops automatic advancement of the piece.
 func (game *Game) stopTicker() {
-	game.ticker.Stop()
+	if game.ticker != nil {
+		game.ticker.Stop()
+	}
 }
 
 // A game event, generated by user input or by the game ticker.
@@ -74,8 +108,41 @@ const (
 	Quit
 	// An event that doesn't cause a change to game state but causes a full redraw; e.g., a window resize.
 	Redraw
+	// Toggles the ghost/shadow piece preview on or off.
+	ToggleGhost
+	// Swaps the current piece into the hold slot, as described on Game.Hold().
+	Hold
+	// Emitted by the game ticker (or, in a NetGame, forwarded from the opponent's ticker) to advance the
+	// current piece down one cell by gravity. Unlike MoveDown, this never awards soft-drop points.
+	Gravity
 )
 
+// applyEvent updates game state for a single GameEvent. It handles every event except Quit and Redraw,
+// which affect the outer control loop (in Start, or in NetGame) rather than game state, so callers are
+// expected to handle those themselves before falling back to applyEvent.
+func (game *Game) applyEvent(event GameEvent) {
+	switch event {
+	case MoveLeft:
+		game.Move(Left)
+	case MoveRight:
+		game.Move(Right)
+	case MoveDown:
+		game.Move(Down)
+	case Gravity:
+		game.moveDown(false)
+	case QuickDrop:
+		game.QuickDrop()
+	case Rotate:
+		game.Rotate()
+	case Pause:
+		game.PauseToggle()
+	case ToggleGhost:
+		game.ghostEnabled = !game.ghostEnabled
+	case Hold:
+		game.Hold()
+	}
+}
+
 // Start running the game. It will continue indefinitely until the user exits.
 func (game *Game) Start() {
 
@@ -94,7 +161,7 @@ gameOver:
 		select {
 		case event = <-eventQueue:
 		case <-game.ticker.C:
-			event = MoveDown
+			event = Gravity
 		}
 		// If the game is paused, ignore all commands except for Pause, Quit, and Redraw. On Redraw, redraw
 		// the pause screen.
@@ -110,22 +177,12 @@ gameOver:
 			}
 		} else {
 			switch event {
-			case MoveLeft:
-				game.Move(Left)
-			case MoveRight:
-				game.Move(Right)
-			case MoveDown:
-				game.Move(Down)
-			case QuickDrop:
-				game.QuickDrop()
-			case Rotate:
-				game.Rotate()
-			case Pause:
-				game.PauseToggle()
 			case Quit:
 				return
 			case Redraw:
 				drawStaticBoardParts()
+			default:
+				game.applyEvent(event)
 			}
 			// Update screen only if game is not now paused.
 			if !game.paused {
@@ -152,6 +209,8 @@ func waitForUserEvent() GameEvent {
 	switch event := termbox.PollEvent(); event.Type {
 	// Movement: arrow keys or vim controls (h, j, k, l)
 	// Pause: 'p'
+	// Toggle ghost piece: 'g'
+	// Hold piece: 'c'
 	// Exit: 'q' or ctrl-c.
 	case termbox.EventKey:
 		if event.Ch == 0 { // A special key combo was pressed
@@ -183,6 +242,10 @@ func waitForUserEvent() GameEvent {
 				return MoveRight
 			case 'j':
 				return MoveDown
+			case 'g':
+				return ToggleGhost
+			case 'c':
+				return Hold
 			}
 		}
 	case termbox.EventResize:
@@ -193,9 +256,11 @@ func waitForUserEvent() GameEvent {
 	return Redraw // Should never be reached
 }
 
-// Randomly choose a new game piece from among the the available pieces.
+// Choose a new game piece by drawing from the current 7-bag. Returns a copy, not a pointer into
+// game.pieces, so mutating its rotation doesn't bleed back into game.pieces.
 func (game *Game) GeneratePiece() *Piece {
-	return &game.pieces[rand.Intn(len(game.pieces))]
+	piece := game.pieces[game.bag.getPiece()]
+	return &piece
 }
 
 // Anchor the current piece to the board, clears lines, increments the score, and generate a new piece. Sets
@@ -207,89 +272,224 @@ func (game *Game) anchor() {
 	rowsCleared := game.board.clearedRows()
 
 	if len(rowsCleared) > 0 {
-		// Animate the cleared rows disappearing
 		game.stopTicker()
-		flickerCells := make(map[Vector]termbox.Attribute)
-		for _, y := range rowsCleared {
-			for x := 0; x < width; x++ {
-				point := Vector{x, y}
-				flickerCells[point] = game.board.cells[point]
+
+		// Animate the cleared rows disappearing. A silent game (NetGame's remote mirror) skips this: it's
+		// drawn only via drawBoardAt at a different screen offset, so flickering these cells would instead
+		// paint over the local player's board.
+		if !game.silent {
+			flickerCells := make(map[Vector]termbox.Attribute)
+			for _, y := range rowsCleared {
+				for x := 0; x < width; x++ {
+					point := Vector{x, y}
+					flickerCells[point] = game.board.cells[point]
+				}
 			}
-		}
-		for i := 0; i < 5; i++ {
-			for point, color := range flickerCells {
-				if i%2 == 0 {
-					color = backgroundColor
+			for i := 0; i < 5; i++ {
+				for point, color := range flickerCells {
+					if i%2 == 0 {
+						color = backgroundColor
+					}
+					setBoardCell((point.x*2)+2, headerHeight+point.y+2, color)
 				}
-				setBoardCell((point.x*2)+2, headerHeight+point.y+2, color)
+				termbox.Flush()
+				time.Sleep(80 * time.Millisecond)
 			}
-			termbox.Flush()
-			time.Sleep(80 * time.Millisecond)
 		}
 
 		// Get rid of the rows
 		game.board.clearRows()
 
-		// Scoring -- 1 row -> 100, 2 rows -> 200, ... 4 rows -> 800
-		points := 100 * math.Pow(2, float64(len(rowsCleared)-1))
-		game.score += int(points)
+		// Scoring follows the guideline model: single/double/triple/tetris worth 100/300/500/800, scaled by
+		// the level at the time of the clear.
+		var linePoints int
+		switch len(rowsCleared) {
+		case 1:
+			linePoints = 100
+		case 2:
+			linePoints = 300
+		case 3:
+			linePoints = 500
+		case 4:
+			linePoints = 800
+		}
+		game.score += linePoints * game.level
+
+		game.linesCleared += len(rowsCleared)
+		game.level = game.linesCleared/10 + 1
 
 		game.startTicker()
+
+		if game.onLinesCleared != nil {
+			game.onLinesCleared(len(rowsCleared))
+		}
 	}
 
-	// Bring in the next piece.
+	// Bring in the next piece, and allow holding again now that it's anchored.
 	game.board.currentPiece = game.nextPiece
-	game.board.currentPosition = Vector{initialX, 0}
+	game.board.currentPosition = game.board.currentPiece.initialLocation
 	game.nextPiece = game.GeneratePiece()
 	game.nextPiece.currentRotation = 0
+	game.holdUsed = false
 
 	if game.board.currentPieceInCollision() {
 		game.over = true
 	}
 }
 
-// Attempt to move.
+// Attempt to move. A player-initiated move down (as opposed to one driven by gravity; see moveDown) scores
+// soft-drop points.
 func (game *Game) Move(where Direction) {
-	translation := Vector{0, 0}
 	switch where {
 	case Down:
-		translation = Vector{0, 1}
+		game.moveDown(true)
 	case Left:
-		translation = Vector{-1, 0}
+		game.board.moveIfPossible(Vector{-1, 0})
 	case Right:
-		translation = Vector{1, 0}
+		game.board.moveIfPossible(Vector{1, 0})
 	}
-	// Attempt to make the move.
-	moved := game.board.moveIfPossible(translation)
+}
 
-	// Perform anchoring if we tried to move down but we were unsuccessful.
-	if where == Down && !moved {
+// moveDown attempts to move the current piece down one cell, anchoring it if it can't. awardSoftDrop scores
+// soft-drop points; pass false for automatic gravity ticks.
+func (game *Game) moveDown(awardSoftDrop bool) {
+	moved := game.board.moveIfPossible(Vector{0, 1})
+	if moved {
+		if awardSoftDrop {
+			// Soft-drop points: 1 per cell moved down.
+			game.score++
+		}
+	} else {
+		// Perform anchoring if we tried to move down but we were unsuccessful.
 		game.anchor()
 	}
 }
 
 // Drop the piece all the way and anchor it.
 func (game *Game) QuickDrop() {
-	// Move down as far as possible
+	// Move down as far as possible, tracking how far it fell for hard-drop points (2 per cell).
+	cellsFallen := 0
 	for game.board.moveIfPossible(Vector{0, 1}) {
+		cellsFallen++
+	}
+	game.score += cellsFallen * 2
+
+	if !game.silent {
+		game.DrawDynamic(false)
 	}
-	game.DrawDynamic(false)
 	game.anchor()
 }
 
-// Rotates the current game piece, if possible.
+// Rotate the current piece, trying each SRS wall kick offset in turn until one doesn't collide.
 func (game *Game) Rotate() {
-	game.board.currentPiece.rotate()
+	piece := game.board.currentPiece
+	from := piece.currentRotation
+	piece.rotate()
+	to := piece.currentRotation
+
+	for _, offset := range piece.kickCandidates(from, to) {
+		if game.board.moveIfPossible(offset) {
+			return
+		}
+	}
+	piece.currentRotation = from
+}
+
+// Hold swaps the current piece into the hold slot, pulling in the held (or next) piece in its place. Only
+// one hold is allowed per drop; anchor() resets holdUsed for the next one.
+func (game *Game) Hold() {
+	if game.holdUsed {
+		return
+	}
+	game.holdUsed = true
+
+	current := game.board.currentPiece
+	current.currentRotation = 0
+
+	if game.heldPiece == nil {
+		game.heldPiece = current
+		game.board.currentPiece = game.nextPiece
+		game.nextPiece = game.GeneratePiece()
+		game.nextPiece.currentRotation = 0
+	} else {
+		game.board.currentPiece = game.heldPiece
+		game.heldPiece = current
+	}
+	game.board.currentPiece.currentRotation = 0
+	game.board.currentPosition = game.board.currentPiece.initialLocation
+}
+
+// ghostPosition returns where the current piece would land on a hard drop, leaving the real position untouched.
+func (game *Game) ghostPosition() Vector {
+	original := game.board.currentPosition
+	for {
+		game.board.currentPosition = game.board.currentPosition.plus(Vector{0, 1})
+		if game.board.currentPieceInCollision() {
+			game.board.currentPosition = game.board.currentPosition.plus(Vector{0, -1})
+			break
+		}
+	}
+	ghostPosition := game.board.currentPosition
+	game.board.currentPosition = original
+	return ghostPosition
+}
+
+// drawBoardAt renders just this game's board contents (no preview panes or score), offset xOffset cells to
+// the right of the normal board position.
+func (game *Game) drawBoardAt(xOffset int) {
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			color := game.board.CellColor(Vector{x, y})
+			setBoardCell(xOffset+(x*2)+2, headerHeight+y+2, color)
+		}
+	}
+}
+
+// ReceiveGarbage pushes one solid garbage row onto the bottom of the board for each entry in gaps, with a
+// gap at the given column.
+func (game *Game) ReceiveGarbage(gaps []int) {
+	for _, gap := range gaps {
+		game.pushGarbageRow(gap)
+	}
+	// The current piece rose along with the stack (see pushGarbageRow); recheck it at its new position.
 	if game.board.currentPieceInCollision() {
-		game.board.currentPiece.unrotate()
+		game.over = true
+	}
+}
+
+// pushGarbageRow shifts the whole board, including the current piece's position, up by one row, and fills
+// in the row now vacated at the bottom with a solid garbage row, save for the given gap column.
+func (game *Game) pushGarbageRow(gap int) {
+	game.board.currentPosition = game.board.currentPosition.plus(Vector{0, -1})
+	for y := 0; y < height-1; y++ {
+		for x := 0; x < width; x++ {
+			game.board.cells[Vector{x, y}] = game.board.cells[Vector{x, y + 1}]
+		}
+	}
+	for x := 0; x < width; x++ {
+		if x == gap {
+			delete(game.board.cells, Vector{x, height - 1})
+		} else {
+			game.board.cells[Vector{x, height - 1}] = garbageColor
+		}
 	}
 }
 
 // Draw the dynamic parts of the game interface (the board, the next piece preview pane, and the score).  The
-// static parts should be drawn with the drawStaticBoardParts() function, if needed.  If clearOnly is true, 
-// the board and preview pane will be cleared rather than redrawn. 
+// static parts should be drawn with the drawStaticBoardParts() function, if needed.  If clearOnly is true,
+// the board and preview pane will be cleared rather than redrawn.
 func (game *Game) DrawDynamic(clearOnly bool) {
 
+	// Figure out where the current piece would land on a hard drop, so we can draw a ghost outline there
+	// before the active piece is drawn on top of it.
+	ghostCells := make(map[Vector]bool)
+	if !clearOnly && game.ghostEnabled {
+		ghostPosition := game.ghostPosition()
+		for _, point := range game.board.currentPiece.instance() {
+			ghostCells[point.plus(ghostPosition)] = true
+		}
+	}
+
 	// Print the board contents. Each block will correspond to a side-by-side pair of cells in the termbox, so
 	// that the visible blocks will be roughly square.  If clearOnly is true, draw background color.
 	for x := 0; x < width; x++ {
@@ -298,6 +498,9 @@ func (game *Game) DrawDynamic(clearOnly bool) {
 				setBoardCell((x*2)+2, headerHeight+y+2, backgroundColor)
 			} else {
 				color := game.board.CellColor(Vector{x, y})
+				if color == backgroundColor && ghostCells[Vector{x, y}] {
+					color = termbox.AttrReverse
+				}
 				setBoardCell((x*2)+2, headerHeight+y+2, color)
 			}
 		}
@@ -318,9 +521,25 @@ func (game *Game) DrawDynamic(clearOnly bool) {
 		}
 	}
 
+	// Print the held piece. Same box dimensions as the next-piece pane above, just stacked below it. Need to
+	// clear the box first, then draw the held piece only if clearOnly is false and something is being held.
+	heldPieceOffset := Vector{(width * 2) + 8, headerHeight + previewHeight + 3}
+	for x := 0; x < 6; x++ {
+		for y := 0; y < 4; y++ {
+			cursor := heldPieceOffset.plus(Vector{x, y})
+			setCell(cursor.x, cursor.y, ' ', termbox.ColorDefault)
+		}
+	}
+	if !clearOnly && game.heldPiece != nil {
+		for _, point := range game.heldPiece.rotations[0] {
+			cursor := heldPieceOffset.plus(Vector{point.x * 2, point.y})
+			setBoardCell(cursor.x, cursor.y, game.heldPiece.color)
+		}
+	}
+
 	// Draw the current score.  If clearOnly, do the same.
 	score := game.score
-	cursor := Vector{(width * 2) + 18, headerHeight + previewHeight + 7}
+	cursor := Vector{(width * 2) + 18, headerHeight + (previewHeight * 2) + 7}
 	for {
 		digit := score % 10
 		score /= 10
@@ -331,6 +550,32 @@ func (game *Game) DrawDynamic(clearOnly bool) {
 		}
 	}
 
+	// Draw the current level, just below the score.
+	level := game.level
+	cursor = Vector{(width * 2) + 18, headerHeight + (previewHeight * 2) + 13}
+	for {
+		digit := level % 10
+		level /= 10
+		drawDigitAsAscii(cursor.x, cursor.y, digit)
+		cursor = cursor.plus(Vector{-4, 0})
+		if level == 0 {
+			break
+		}
+	}
+
+	// Draw the total line count, just below the level.
+	linesCleared := game.linesCleared
+	cursor = Vector{(width * 2) + 18, headerHeight + (previewHeight * 2) + 19}
+	for {
+		digit := linesCleared % 10
+		linesCleared /= 10
+		drawDigitAsAscii(cursor.x, cursor.y, digit)
+		cursor = cursor.plus(Vector{-4, 0})
+		if linesCleared == 0 {
+			break
+		}
+	}
+
 	// Flush termbox's internal state to the screen.
 	termbox.Flush()
 }
@@ -0,0 +1,259 @@
+package tetris
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// garbageColor is the color used to draw garbage rows sent by an opponent.
+const garbageColor = termbox.ColorWhite
+
+// NetGame is a two-player versus match played over a single TCP connection. local is the board the player
+// actually plays; remote is a silent mirror of the opponent's board, driven by messages read off the wire.
+type NetGame struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	local  *Game
+	remote *Game
+
+	tick     int
+	incoming chan netMessage
+}
+
+// netMessage is one parsed line of the wire protocol read by readLoop.
+type netMessage struct {
+	kind  string // "event" or "garbage"
+	event GameEvent
+	gaps  []int
+}
+
+// Host listens on addr for a single opponent to Join, then sends the shared 7-bag seed as a "SEED <n>"
+// handshake line.
+func Host(addr string) (*NetGame, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := listener.Accept()
+	listener.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	seed := rand.Int63()
+	if _, err := fmt.Fprintf(conn, "SEED %d\n", seed); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newNetGame(conn, bufio.NewReader(conn), seed), nil
+}
+
+// Join connects to a match started with Host(addr) and waits for its "SEED <n>" handshake line.
+func Join(addr string) (*NetGame, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var seed int64
+	if _, err := fmt.Sscanf(strings.TrimSpace(line), "SEED %d", &seed); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tetris: expected a SEED handshake, got %q", line)
+	}
+
+	return newNetGame(conn, reader, seed), nil
+}
+
+// newNetGame builds a NetGame around a connection and the bufio.Reader already wrapping it, so Join can
+// hand over the same reader it used for the SEED handshake.
+func newNetGame(conn net.Conn, reader *bufio.Reader, seed int64) *NetGame {
+	netGame := &NetGame{
+		conn:     conn,
+		reader:   reader,
+		local:    newGame(tetrisPieces(), seed, false),
+		remote:   newGame(tetrisPieces(), seed, true),
+		incoming: make(chan netMessage, 100),
+	}
+	netGame.local.onLinesCleared = netGame.sendGarbage
+	go netGame.readLoop()
+	return netGame
+}
+
+// readLoop parses incoming protocol lines and forwards them to incoming. It closes incoming (rather than
+// the connection) when the opponent disconnects, so Start can notice and end the round.
+func (netGame *NetGame) readLoop() {
+	defer close(netGame.incoming)
+	for {
+		line, err := netGame.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "EVENT":
+			if len(fields) != 3 {
+				continue
+			}
+			event, err := strconv.Atoi(fields[2])
+			if err != nil {
+				continue
+			}
+			netGame.incoming <- netMessage{kind: "event", event: GameEvent(event)}
+		case "GARBAGE":
+			gaps := make([]int, 0, len(fields)-1)
+			valid := true
+			for _, field := range fields[1:] {
+				gap, err := strconv.Atoi(field)
+				if err != nil {
+					valid = false
+					break
+				}
+				gaps = append(gaps, gap)
+			}
+			if !valid {
+				continue
+			}
+			netGame.incoming <- netMessage{kind: "garbage", gaps: gaps}
+		}
+	}
+}
+
+// sendEvent forwards a visible local GameEvent to the opponent, tagged with the current tick.
+func (netGame *NetGame) sendEvent(event GameEvent) {
+	fmt.Fprintf(netGame.conn, "EVENT %d %d\n", netGame.tick, int(event))
+}
+
+// sendGarbage is registered as local.onLinesCleared: clearing 2 or more lines chooses n-1 garbage gap
+// columns, applies them to our mirror of the opponent's board, and transmits them to the opponent.
+func (netGame *NetGame) sendGarbage(rowsCleared int) {
+	if rowsCleared < 2 {
+		return
+	}
+	gaps := make([]int, rowsCleared-1)
+	fields := make([]string, len(gaps))
+	for i := range gaps {
+		gaps[i] = rand.Intn(width)
+		fields[i] = strconv.Itoa(gaps[i])
+	}
+	netGame.remote.ReceiveGarbage(gaps)
+	fmt.Fprintf(netGame.conn, "GARBAGE %s\n", strings.Join(fields, " "))
+}
+
+// isGameplayEvent reports whether event moves the game forward, as opposed to a local-only UI event like
+// Pause or ToggleGhost. Only gameplay events are forwarded to the opponent or replayed onto netGame.remote.
+func isGameplayEvent(event GameEvent) bool {
+	switch event {
+	case MoveLeft, MoveRight, MoveDown, Rotate, QuickDrop, Hold, Gravity:
+		return true
+	default:
+		return false
+	}
+}
+
+// Start runs the versus match until either player's board tops out or the connection drops. Local input
+// drives netGame.local and is forwarded to the opponent; messages received from the opponent drive
+// netGame.remote, the on-screen mirror of their board.
+func (netGame *NetGame) Start() {
+	drawStaticBoardParts()
+	netGame.DrawVersus()
+
+	eventQueue := make(chan GameEvent, 100)
+	go func() {
+		for {
+			eventQueue <- waitForUserEvent()
+		}
+	}()
+
+	for {
+		select {
+		case event := <-eventQueue:
+			// If the game is paused, ignore all commands except for Pause, Quit, and Redraw, mirroring
+			// Game.Start(). Pause never reaches the opponent; see isGameplayEvent.
+			if netGame.local.paused {
+				switch event {
+				case Pause:
+					netGame.local.PauseToggle()
+				case Quit:
+					netGame.conn.Close()
+					return
+				case Redraw:
+					drawStaticBoardParts()
+					netGame.local.DrawPauseScreen()
+				}
+				continue
+			}
+			switch event {
+			case Quit:
+				netGame.conn.Close()
+				return
+			case Redraw:
+				drawStaticBoardParts()
+			default:
+				netGame.tick++
+				netGame.local.applyEvent(event)
+				if isGameplayEvent(event) {
+					netGame.sendEvent(event)
+				}
+			}
+
+		case <-netGame.local.ticker.C:
+			netGame.tick++
+			netGame.local.applyEvent(Gravity)
+			netGame.sendEvent(Gravity)
+
+		case msg, ok := <-netGame.incoming:
+			if !ok {
+				netGame.conn.Close()
+				return
+			}
+			switch msg.kind {
+			case "event":
+				if isGameplayEvent(msg.event) {
+					netGame.remote.applyEvent(msg.event)
+				}
+			case "garbage":
+				netGame.local.ReceiveGarbage(msg.gaps)
+			}
+		}
+
+		// Skip the redraw while paused: PauseToggle() has already drawn the pause screen above, and
+		// redrawing here would immediately paint over it.
+		if !netGame.local.paused {
+			netGame.DrawVersus()
+		}
+		if netGame.local.over || netGame.remote.over {
+			break
+		}
+	}
+
+	netGame.conn.Close()
+	netGame.local.DrawGameOver()
+	for waitForUserEvent() != Quit {
+	}
+}
+
+// DrawVersus splits the screen horizontally: the local player's board is drawn with its full UI (preview,
+// hold, score) on the left, and a plain view of the opponent's mirrored board is drawn alongside it on the
+// right.
+func (netGame *NetGame) DrawVersus() {
+	netGame.local.DrawDynamic(false)
+	netGame.remote.drawBoardAt(totalWidth + 4)
+	termbox.Flush()
+}
@@ -2,16 +2,16 @@ package tetris
 
 import (
 	"math/rand"
-	"time"
 
 	"github.com/nsf/termbox-go"
 )
 
 // Bag stores the piece number and pieces
 type Bag struct {
+	size        int
 	pieceNumber int
 	pieces      []int
-	bagNumber   int
+	rng         *rand.Rand
 }
 
 // PieceInstance stores a piece's particular rotational instance
@@ -27,24 +27,30 @@ type Piece struct {
 	color           termbox.Attribute
 }
 
-// initialize is called after each bag has been
+// newBag creates an empty Bag over the given number of distinct pieces, seeded with seed. It initializes
+// (shuffles) itself the first time getPiece() is called.
+func newBag(size int, seed int64) *Bag {
+	return &Bag{size: size, pieceNumber: -1, rng: rand.New(rand.NewSource(seed))}
+}
+
+// initialize is called after each bag has been exhausted; it refills and reshuffles the bag.
 func (b *Bag) initialize() {
-	b.pieceNumber = 6 // reset the piece number
-	b.shuffle()       // randomize this bag
+	b.pieceNumber = b.size - 1 // reset the piece number
+	b.shuffle()                // randomize this bag
 }
 
 func (b *Bag) shuffle() {
-	b.pieces = []int{0, 1, 2, 3, 4, 5, 6} // piece set is constant
-	rand.Seed(time.Now().UnixNano())      // seed rng and shuffle the bag!
-	rand.Shuffle(len(b.pieces), func(i, j int) { b.pieces[i], b.pieces[j] = b.pieces[j], b.pieces[i] })
+	b.pieces = make([]int, b.size)
+	for i := range b.pieces {
+		b.pieces[i] = i
+	}
+	b.rng.Shuffle(len(b.pieces), func(i, j int) { b.pieces[i], b.pieces[j] = b.pieces[j], b.pieces[i] })
 }
 
 func (b *Bag) getPiece() (retPiece int) {
 	// call to reset pieceNumber and reshuffle after every bag
 	if b.pieceNumber < 0 {
 		b.initialize()
-		// increment our bag number
-		b.bagNumber++
 	}
 	retPiece = b.pieces[b.pieceNumber]
 	// decrement piece number in bag and return piece
@@ -56,6 +62,52 @@ func (b *Bag) getPiece() (retPiece int) {
 	return p.name
 } */
 
+// rotationTransition identifies a rotation by its "from" and "to" orientation states (0-3).
+type rotationTransition struct {
+	from, to int
+}
+
+// jlstzKicks is the standard SRS wall kick table shared by the J, L, S, T and Z pieces.
+var jlstzKicks = map[rotationTransition][5]Vector{
+	{0, 1}: {{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}},
+	{1, 0}: {{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}},
+	{1, 2}: {{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}},
+	{2, 1}: {{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}},
+	{2, 3}: {{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},
+	{3, 2}: {{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}},
+	{3, 0}: {{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}},
+	{0, 3}: {{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},
+}
+
+// iKicks is the I-piece's own SRS wall kick table.
+var iKicks = map[rotationTransition][5]Vector{
+	{0, 1}: {{0, 0}, {-2, 0}, {1, 0}, {-2, -1}, {1, 2}},
+	{1, 0}: {{0, 0}, {2, 0}, {-1, 0}, {2, 1}, {-1, -2}},
+	{1, 2}: {{0, 0}, {-1, 0}, {2, 0}, {-1, 2}, {2, -1}},
+	{2, 1}: {{0, 0}, {1, 0}, {-2, 0}, {1, -2}, {-2, 1}},
+	{2, 3}: {{0, 0}, {2, 0}, {-1, 0}, {2, 1}, {-1, -2}},
+	{3, 2}: {{0, 0}, {-2, 0}, {1, 0}, {-2, -1}, {1, 2}},
+	{3, 0}: {{0, 0}, {1, 0}, {-2, 0}, {1, -2}, {-2, 1}},
+	{0, 3}: {{0, 0}, {-1, 0}, {2, 0}, {-1, 2}, {2, -1}},
+}
+
+// kickCandidates returns the ordered offsets to try when rotating this piece from state "from" to "to".
+// The O-piece never needs kicks.
+func (p *Piece) kickCandidates(from, to int) []Vector {
+	if p.name == "O" {
+		return nil
+	}
+	table := jlstzKicks
+	if p.name == "I" {
+		table = iKicks
+	}
+	offsets, ok := table[rotationTransition{from, to}]
+	if !ok {
+		return []Vector{{0, 0}}
+	}
+	return offsets[:]
+}
+
 // Find the current PieceInstance of this piece.
 func (p *Piece) instance() PieceInstance {
 	return p.rotations[p.currentRotation]
@@ -66,22 +118,6 @@ func (p *Piece) rotate() {
 	p.currentRotation = (p.currentRotation + 1) % len(p.rotations)
 }
 
-// Go to the previous rotation.
-func (p *Piece) unrotate() {
-	p.currentRotation = (p.currentRotation - 1) % len(p.rotations)
-	if p.currentRotation < 0 {
-		p.currentRotation += len(p.rotations)
-	}
-}
-
-// Rotate the piece 180 degrees
-func (p *Piece) dblrotate() {
-	p.currentRotation = (p.currentRotation + 2) % len(p.rotations)
-	if p.currentRotation < 0 {
-		p.currentRotation += len(p.rotations)
-	}
-}
-
 // This has all the hard-coded tetris pieces.
 // TODO: It might be nice to have a way to parse these from a configuration file. Maybe the format could look
 // something like this:
@@ -102,10 +138,20 @@ func tetrisPieces() []Piece {
 			[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{0, 1}, Vector{1, 1}}},
 			0, Vector{4, 0}, termbox.ColorYellow},
 		Piece{"Z",
-			[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{1, 1}, Vector{2, 1}}, []Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{0, 2}}},
+			[]PieceInstance{
+				[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{1, 1}, Vector{2, 1}},
+				[]Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{0, 2}},
+				[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{1, 2}, Vector{2, 2}},
+				[]Vector{Vector{2, 0}, Vector{1, 1}, Vector{2, 1}, Vector{1, 2}},
+			},
 			0, Vector{3, 0}, termbox.ColorRed},
 		Piece{"S",
-			[]PieceInstance{[]Vector{Vector{1, 0}, Vector{2, 0}, Vector{0, 1}, Vector{1, 1}}, []Vector{Vector{0, 0}, Vector{0, 1}, Vector{1, 1}, Vector{1, 2}}},
+			[]PieceInstance{
+				[]Vector{Vector{1, 0}, Vector{2, 0}, Vector{0, 1}, Vector{1, 1}},
+				[]Vector{Vector{0, 0}, Vector{0, 1}, Vector{1, 1}, Vector{1, 2}},
+				[]Vector{Vector{1, 1}, Vector{2, 1}, Vector{0, 2}, Vector{1, 2}},
+				[]Vector{Vector{1, 0}, Vector{1, 1}, Vector{2, 1}, Vector{2, 2}},
+			},
 			0, Vector{3, 0}, termbox.ColorGreen},
 		Piece{"T",
 			[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{2, 0}, Vector{1, 1}}, []Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{1, 2}}, []Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{2, 1}}, []Vector{Vector{0, 0}, Vector{0, 1}, Vector{1, 1}, Vector{0, 2}}},
@@ -117,7 +163,12 @@ func tetrisPieces() []Piece {
 			[]PieceInstance{[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{2, 2}}, []Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{0, 2}}, []Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{0, 0}}, []Vector{Vector{1, 0}, Vector{2, 0}, Vector{1, 1}, Vector{1, 2}}},
 			0, Vector{3, -1}, termbox.ColorBlue},
 		Piece{"I",
-			[]PieceInstance{[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{3, 1}}, []Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{1, 3}}},
+			[]PieceInstance{
+				[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{3, 1}},
+				[]Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{1, 3}},
+				[]Vector{Vector{0, 2}, Vector{1, 2}, Vector{2, 2}, Vector{3, 2}},
+				[]Vector{Vector{2, 0}, Vector{2, 1}, Vector{2, 2}, Vector{2, 3}},
+			},
 			0, Vector{3, -1}, termbox.ColorCyan},
 	}
 }
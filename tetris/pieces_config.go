@@ -0,0 +1,156 @@
+package tetris
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// pieceColors maps the color names accepted in a piece configuration file to the termbox.Attribute used to
+// draw them.
+var pieceColors = map[string]termbox.Attribute{
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// LoadPieces parses the text piece-configuration format hinted at by the TODO comment above tetrisPieces().
+// Each piece starts with a header line of the form "<name> <color>" (e.g. "T magenta"), followed by one or
+// more ASCII-art grids, one per rotation, using '#' for a filled cell and ' ' for an empty one:
+//
+//	T magenta
+//	###
+//	 #
+//
+//	J blue
+//	 #
+//	 #
+//	##
+//
+// A blank line ends the current rotation's grid; the next non-blank line either starts another rotation
+// (plain grid content) or, if it parses as a header, the next piece.
+func LoadPieces(r io.Reader) ([]Piece, error) {
+	var pieces []Piece
+	var current *Piece
+	var grid []string
+
+	flushGrid := func() error {
+		if len(grid) == 0 {
+			return nil
+		}
+		instance, initialLocation := pieceGridToInstance(grid)
+		if len(instance) == 0 {
+			return fmt.Errorf("tetris: piece %q has a rotation with no filled cells", current.name)
+		}
+		if len(current.rotations) == 0 {
+			current.initialLocation = initialLocation
+		}
+		current.rotations = append(current.rotations, instance)
+		grid = nil
+		return nil
+	}
+
+	flushPiece := func() error {
+		if current == nil {
+			return nil
+		}
+		if err := flushGrid(); err != nil {
+			return err
+		}
+		if len(current.rotations) == 0 {
+			return fmt.Errorf("tetris: piece %q has no rotations", current.name)
+		}
+		pieces = append(pieces, *current)
+		current = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimRight(line, " \t\r")
+
+		if trimmed == "" {
+			if err := flushGrid(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if name, color, ok := parsePieceHeader(trimmed); ok {
+			if err := flushPiece(); err != nil {
+				return nil, err
+			}
+			current = &Piece{name: name, color: color}
+			continue
+		}
+
+		// A line shaped like a header (two fields) but with an unrecognized color is a mistyped header,
+		// not a grid row; reject it instead of silently absorbing it into the previous piece's shape.
+		if current == nil || looksLikeHeader(trimmed) {
+			return nil, fmt.Errorf("tetris: expected a \"<name> <color>\" header, got %q", line)
+		}
+		grid = append(grid, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flushPiece(); err != nil {
+		return nil, err
+	}
+
+	return pieces, nil
+}
+
+// parsePieceHeader recognizes a "<name> <color>" header line, such as "T magenta".
+func parsePieceHeader(line string) (name string, color termbox.Attribute, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return "", 0, false
+	}
+	color, known := pieceColors[strings.ToLower(fields[1])]
+	if !known {
+		return "", 0, false
+	}
+	return fields[0], color, true
+}
+
+// looksLikeHeader reports whether line has a header's two-field shape, regardless of whether its color is
+// recognized. A line made only of '#' cells and spaces (such as "# #") is a grid row, not a header.
+func looksLikeHeader(line string) bool {
+	return len(strings.Fields(line)) == 2 && strings.Trim(line, "# ") != ""
+}
+
+// pieceGridToInstance converts one ASCII-art rotation grid into a PieceInstance, plus a spawn location
+// derived from the grid's bounding box.
+func pieceGridToInstance(grid []string) (PieceInstance, Vector) {
+	var instance PieceInstance
+	minX, minY := 0, 0
+	first := true
+
+	for y, row := range grid {
+		for x, ch := range row {
+			if ch != '#' {
+				continue
+			}
+			instance = append(instance, Vector{x, y})
+			if first || x < minX {
+				minX = x
+			}
+			if first || y < minY {
+				minY = y
+			}
+			first = false
+		}
+	}
+
+	return instance, Vector{3 - minX, -minY}
+}
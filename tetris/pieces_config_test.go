@@ -0,0 +1,95 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestLoadPiecesParsesRotationsAndColor(t *testing.T) {
+	input := `T magenta
+###
+ #
+
+J blue
+ #
+ #
+##
+`
+	pieces, err := LoadPieces(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LoadPieces returned an error: %v", err)
+	}
+	if len(pieces) != 2 {
+		t.Fatalf("got %d pieces, want 2", len(pieces))
+	}
+
+	t2 := pieces[0]
+	if t2.name != "T" || t2.color != termbox.ColorMagenta {
+		t.Errorf("got piece %+v, want name T color magenta", t2)
+	}
+	if len(t2.rotations) != 1 {
+		t.Fatalf("got %d rotations for T, want 1", len(t2.rotations))
+	}
+	want := PieceInstance{{0, 0}, {1, 0}, {2, 0}, {1, 1}}
+	if !instanceEqual(t2.rotations[0], want) {
+		t.Errorf("got T rotation %v, want %v", t2.rotations[0], want)
+	}
+
+	j := pieces[1]
+	if j.name != "J" || j.color != termbox.ColorBlue {
+		t.Errorf("got piece %+v, want name J color blue", j)
+	}
+}
+
+func TestLoadPiecesRejectsMalformedHeaderMidPiece(t *testing.T) {
+	input := `T magenta
+###
+ #
+
+Q orange
+##
+`
+	_, err := LoadPieces(strings.NewReader(input))
+	if err == nil {
+		t.Fatal("expected an error for the unrecognized color, got nil")
+	}
+	if !strings.Contains(err.Error(), "expected a") {
+		t.Errorf("got error %q, want it to mention the expected header format", err)
+	}
+}
+
+func TestLoadPiecesRejectsLeadingGridContent(t *testing.T) {
+	_, err := LoadPieces(strings.NewReader("###\n"))
+	if err == nil {
+		t.Fatal("expected an error for grid content with no preceding header, got nil")
+	}
+}
+
+func TestLoadPiecesRejectsEmptyRotation(t *testing.T) {
+	input := "T magenta\n...\n"
+	_, err := LoadPieces(strings.NewReader(input))
+	if err == nil {
+		t.Fatal("expected an error for a rotation with no filled cells, got nil")
+	}
+}
+
+func TestLoadPiecesRejectsPieceWithNoRotations(t *testing.T) {
+	_, err := LoadPieces(strings.NewReader("T magenta\n"))
+	if err == nil {
+		t.Fatal("expected an error for a piece with no rotations, got nil")
+	}
+}
+
+func instanceEqual(a, b PieceInstance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}